@@ -0,0 +1,89 @@
+// Package acoustid is a minimal client for the AcoustID web API
+// (https://acoustid.org/webservice), used to resolve a Chromaprint
+// fingerprint to a recognized recording title and artist.
+package acoustid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// LookupURL is the AcoustID lookup endpoint.
+const LookupURL = "https://api.acoustid.org/v2/lookup"
+
+// AcoustIDClient queries the AcoustID web API.
+type AcoustIDClient struct {
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewAcoustIDClient returns a client authenticated with the given AcoustID
+// application API key.
+func NewAcoustIDClient(apiKey string) *AcoustIDClient {
+	return &AcoustIDClient{
+		apiKey:     apiKey,
+		httpClient: http.DefaultClient,
+	}
+}
+
+type lookupResponse struct {
+	Status string `json:"status"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Results []struct {
+		Score      float64 `json:"score"`
+		Recordings []struct {
+			Title   string `json:"title"`
+			Artists []struct {
+				Name string `json:"name"`
+			} `json:"artists"`
+		} `json:"recordings"`
+	} `json:"results"`
+}
+
+// Lookup resolves a Chromaprint fingerprint to a recording's title and
+// artist. It returns an error if the API call fails or no recording could
+// be identified.
+func (c *AcoustIDClient) Lookup(ctx context.Context, fingerprint string, durationSeconds int) (title, artist string, err error) {
+	query := url.Values{
+		"client":      {c.apiKey},
+		"meta":        {"recordings"},
+		"duration":    {fmt.Sprintf("%d", durationSeconds)},
+		"fingerprint": {fingerprint},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, LookupURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", "", fmt.Errorf("building AcoustID request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("calling AcoustID: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var parsed lookupResponse
+	if err = json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return "", "", fmt.Errorf("decoding AcoustID response: %w", err)
+	}
+	if parsed.Status != "ok" {
+		message := "unknown error"
+		if parsed.Error != nil {
+			message = parsed.Error.Message
+		}
+		return "", "", fmt.Errorf("AcoustID lookup failed: %s", message)
+	}
+	if len(parsed.Results) == 0 || len(parsed.Results[0].Recordings) == 0 {
+		return "", "", fmt.Errorf("no recording identified for fingerprint")
+	}
+
+	recording := parsed.Results[0].Recordings[0]
+	if len(recording.Artists) > 0 {
+		artist = recording.Artists[0].Name
+	}
+	return recording.Title, artist, nil
+}
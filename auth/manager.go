@@ -0,0 +1,244 @@
+// Package auth implements the Spotify authorization code flow: it hands out
+// per-user login URLs, runs the OAuth callback server, and builds
+// user-scoped Spotify clients from stored, auto-refreshed tokens. This is
+// used alongside the bot's client-credentials Spotify client, which only
+// ever searches the public catalog on the bot's own behalf.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+	spotifyauth "github.com/zmb3/spotify/v2/auth"
+	"golang.org/x/oauth2"
+)
+
+// stateTTL is how long an issued OAuth state stays valid; abandoned states
+// (the user never completes the callback) are swept after this.
+const stateTTL = 15 * time.Minute
+
+// lockIdleTTL is how long a per-user lock can go untouched before sweep
+// considers it stale and evicts it.
+const lockIdleTTL = time.Hour
+
+// sweepInterval is how often sweepLoop checks for stale states and locks.
+const sweepInterval = 15 * time.Minute
+
+// pendingState tracks who an issued OAuth state belongs to and when it was
+// issued, so handleCallback can reject it once stateTTL has passed.
+type pendingState struct {
+	userID   int64
+	issuedAt time.Time
+}
+
+// userLock is a per-user mutex plus the last time it was handed out, so
+// sweep can tell idle locks from ones recently in use.
+type userLock struct {
+	mu       sync.Mutex
+	lastUsed time.Time
+}
+
+// Manager drives user authorization and holds the state needed to refresh
+// and reuse tokens afterwards.
+type Manager struct {
+	authenticator *spotifyauth.Authenticator
+	oauth2Config  *oauth2.Config
+	store         TokenStore
+	callbackAddr  string
+
+	statesMu sync.Mutex
+	states   map[string]pendingState // OAuth state -> Telegram user ID + issue time
+
+	locksMu   sync.Mutex
+	userLocks map[int64]*userLock
+
+	// OnAuthorized, if set, is called from the callback server's goroutine
+	// once a user has completed authorization.
+	OnAuthorized func(userID int64)
+}
+
+// NewManager builds a Manager for the given OAuth client credentials and
+// redirect URL. callbackAddr is the address the callback HTTP server listens
+// on, e.g. ":8080"; its port must match the one in redirectURL.
+func NewManager(clientID, clientSecret, redirectURL, callbackAddr string, store TokenStore) *Manager {
+	scopes := []string{
+		spotifyauth.ScopeUserLibraryModify,
+		spotifyauth.ScopePlaylistModifyPublic,
+		spotifyauth.ScopePlaylistModifyPrivate,
+	}
+	m := &Manager{
+		authenticator: spotifyauth.New(
+			spotifyauth.WithClientID(clientID),
+			spotifyauth.WithClientSecret(clientSecret),
+			spotifyauth.WithRedirectURL(redirectURL),
+			spotifyauth.WithScopes(scopes...),
+		),
+		oauth2Config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  spotifyauth.AuthURL,
+				TokenURL: spotifyauth.TokenURL,
+			},
+		},
+		store:        store,
+		callbackAddr: callbackAddr,
+		states:       make(map[string]pendingState),
+		userLocks:    make(map[int64]*userLock),
+	}
+	go m.sweepLoop()
+	return m
+}
+
+// sweepLoop periodically evicts abandoned OAuth states and idle per-user
+// locks, so a long-running bot doesn't accumulate either forever.
+func (m *Manager) sweepLoop() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.sweepStates()
+		m.sweepLocks()
+	}
+}
+
+func (m *Manager) sweepStates() {
+	m.statesMu.Lock()
+	defer m.statesMu.Unlock()
+	cutoff := time.Now().Add(-stateTTL)
+	for state, pending := range m.states {
+		if pending.issuedAt.Before(cutoff) {
+			delete(m.states, state)
+		}
+	}
+}
+
+func (m *Manager) sweepLocks() {
+	m.locksMu.Lock()
+	defer m.locksMu.Unlock()
+	cutoff := time.Now().Add(-lockIdleTTL)
+	for userID, lock := range m.userLocks {
+		if lock.lastUsed.Before(cutoff) {
+			delete(m.userLocks, userID)
+		}
+	}
+}
+
+// AuthURL generates a one-time Spotify authorization URL for the given
+// Telegram user.
+func (m *Manager) AuthURL(userID int64) (string, error) {
+	state, err := randomState()
+	if err != nil {
+		return "", fmt.Errorf("generating OAuth state: %w", err)
+	}
+	m.statesMu.Lock()
+	m.states[state] = pendingState{userID: userID, issuedAt: time.Now()}
+	m.statesMu.Unlock()
+	return m.authenticator.AuthURL(state), nil
+}
+
+func randomState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ListenAndServe runs the OAuth callback HTTP server. It blocks until the
+// server stops and is meant to be run in its own goroutine.
+func (m *Manager) ListenAndServe() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", m.handleCallback)
+	return http.ListenAndServe(m.callbackAddr, mux)
+}
+
+func (m *Manager) handleCallback(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	m.statesMu.Lock()
+	pending, ok := m.states[state]
+	if ok {
+		delete(m.states, state)
+	}
+	m.statesMu.Unlock()
+	if !ok || time.Since(pending.issuedAt) > stateTTL {
+		http.Error(w, "Unknown or expired authorization state", http.StatusBadRequest)
+		return
+	}
+	userID := pending.userID
+
+	token, err := m.authenticator.Token(r.Context(), state, r)
+	if err != nil {
+		http.Error(w, "Failed to complete Spotify authorization", http.StatusInternalServerError)
+		return
+	}
+	if err = m.store.Save(userID, token); err != nil {
+		http.Error(w, "Failed to store Spotify authorization", http.StatusInternalServerError)
+		return
+	}
+
+	if m.OnAuthorized != nil {
+		m.OnAuthorized(userID)
+	}
+	_, _ = w.Write([]byte("Spotify account connected, you can return to Telegram."))
+}
+
+// Logout forgets the stored token for the given user.
+func (m *Manager) Logout(userID int64) error {
+	return m.store.Delete(userID)
+}
+
+// IsAuthorized reports whether a token is stored for the given user.
+func (m *Manager) IsAuthorized(userID int64) (bool, error) {
+	_, ok, err := m.store.Get(userID)
+	return ok, err
+}
+
+// Client returns a Spotify client scoped to userID's authorization,
+// refreshing and persisting the token first if it has expired. Refreshes
+// are serialized per user since the Spotify refresh token can rotate, and a
+// lost refresh token invalidates the whole grant.
+func (m *Manager) Client(ctx context.Context, userID int64) (*spotify.Client, error) {
+	lock := m.userLock(userID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	token, ok, err := m.store.Get(userID)
+	if err != nil {
+		return nil, fmt.Errorf("loading token for user %d: %w", userID, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("user %d has not authorized Spotify", userID)
+	}
+
+	refreshed, err := m.oauth2Config.TokenSource(ctx, token).Token()
+	if err != nil {
+		return nil, fmt.Errorf("refreshing token for user %d: %w", userID, err)
+	}
+	if refreshed.AccessToken != token.AccessToken {
+		if err = m.store.Save(userID, refreshed); err != nil {
+			return nil, fmt.Errorf("persisting refreshed token for user %d: %w", userID, err)
+		}
+	}
+
+	return spotify.New(m.oauth2Config.Client(ctx, refreshed)), nil
+}
+
+func (m *Manager) userLock(userID int64) *sync.Mutex {
+	m.locksMu.Lock()
+	defer m.locksMu.Unlock()
+	lock, ok := m.userLocks[userID]
+	if !ok {
+		lock = &userLock{}
+		m.userLocks[userID] = lock
+	}
+	lock.lastUsed = time.Now()
+	return &lock.mu
+}
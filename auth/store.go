@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// TokenStore persists per-user Spotify OAuth tokens, keyed by Telegram user ID.
+type TokenStore interface {
+	Get(userID int64) (token *oauth2.Token, ok bool, err error)
+	Save(userID int64, token *oauth2.Token) error
+	Delete(userID int64) error
+}
+
+// JSONFileStore is the default TokenStore, backing all tokens in a single JSON file on disk.
+type JSONFileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewJSONFileStore returns a JSONFileStore that persists tokens to path.
+func NewJSONFileStore(path string) *JSONFileStore {
+	return &JSONFileStore{path: path}
+}
+
+func (s *JSONFileStore) load() (map[int64]*oauth2.Token, error) {
+	tokens := make(map[int64]*oauth2.Token)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading token store: %w", err)
+	}
+	if len(data) == 0 {
+		return tokens, nil
+	}
+	if err = json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("parsing token store: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *JSONFileStore) persist(tokens map[int64]*oauth2.Token) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding token store: %w", err)
+	}
+	if err = os.WriteFile(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("writing token store: %w", err)
+	}
+	return nil
+}
+
+func (s *JSONFileStore) Get(userID int64) (*oauth2.Token, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return nil, false, err
+	}
+	token, ok := tokens[userID]
+	return token, ok, nil
+}
+
+func (s *JSONFileStore) Save(userID int64, token *oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	tokens[userID] = token
+	return s.persist(tokens)
+}
+
+func (s *JSONFileStore) Delete(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(tokens, userID)
+	return s.persist(tokens)
+}
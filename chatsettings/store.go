@@ -0,0 +1,80 @@
+// Package chatsettings tracks per-chat bot behavior toggles, such as whether
+// audio matching is enabled in a given group chat.
+package chatsettings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Store persists per-chat settings, keyed by Telegram chat ID. A chat with
+// no stored entry is treated as disabled, so groups are opt-in.
+type Store interface {
+	IsEnabled(chatID int64) (bool, error)
+	SetEnabled(chatID int64, enabled bool) error
+}
+
+// JSONFileStore is the default Store, backed by a single JSON file on disk.
+// A SQLite-backed Store can later implement the same interface without
+// touching call sites.
+type JSONFileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewJSONFileStore returns a JSONFileStore that persists settings to path.
+func NewJSONFileStore(path string) *JSONFileStore {
+	return &JSONFileStore{path: path}
+}
+
+func (s *JSONFileStore) load() (map[int64]bool, error) {
+	enabled := make(map[int64]bool)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return enabled, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading chat settings store: %w", err)
+	}
+	if len(data) == 0 {
+		return enabled, nil
+	}
+	if err = json.Unmarshal(data, &enabled); err != nil {
+		return nil, fmt.Errorf("parsing chat settings store: %w", err)
+	}
+	return enabled, nil
+}
+
+func (s *JSONFileStore) persist(enabled map[int64]bool) error {
+	data, err := json.MarshalIndent(enabled, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding chat settings store: %w", err)
+	}
+	if err = os.WriteFile(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("writing chat settings store: %w", err)
+	}
+	return nil
+}
+
+func (s *JSONFileStore) IsEnabled(chatID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enabled, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	return enabled[chatID], nil
+}
+
+func (s *JSONFileStore) SetEnabled(chatID int64, value bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enabled, err := s.load()
+	if err != nil {
+		return err
+	}
+	enabled[chatID] = value
+	return s.persist(enabled)
+}
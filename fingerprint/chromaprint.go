@@ -0,0 +1,71 @@
+//go:build chromaprint
+
+package fingerprint
+
+// Compute needs libchromaprint's development headers (libchromaprint-dev on
+// Debian/Ubuntu) available at build time. Build with -tags chromaprint to
+// link it in; without the tag, chromaprint_stub.go provides a Compute that
+// always errors, so the rest of the bot still builds and runs without the
+// system library installed.
+
+/*
+#cgo LDFLAGS: -lchromaprint
+#include <chromaprint.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// bytesPerSample is the width of a signed 16-bit PCM sample.
+const bytesPerSample = 2
+
+// Supported reports whether this binary can actually fingerprint audio.
+// It's true here since chromaprint.go is only built with the chromaprint tag.
+func Supported() bool {
+	return true
+}
+
+// Compute reads the raw 16kHz mono PCM file at pcmPath and returns its
+// AcoustID-compatible Chromaprint fingerprint along with the audio's
+// duration in whole seconds.
+func Compute(pcmPath string) (fingerprint string, durationSeconds int, err error) {
+	pcm, err := os.ReadFile(pcmPath)
+	if err != nil {
+		return "", 0, fmt.Errorf("reading PCM file: %w", err)
+	}
+	if len(pcm) < bytesPerSample {
+		return "", 0, fmt.Errorf("PCM file too short to fingerprint")
+	}
+
+	ctx := C.chromaprint_new(C.CHROMAPRINT_ALGORITHM_DEFAULT)
+	if ctx == nil {
+		return "", 0, fmt.Errorf("failed to allocate chromaprint context")
+	}
+	defer C.chromaprint_free(ctx)
+
+	if C.chromaprint_start(ctx, C.int(SampleRate), C.int(Channels)) == 0 {
+		return "", 0, fmt.Errorf("chromaprint_start failed")
+	}
+	samples := (*C.int16_t)(unsafe.Pointer(&pcm[0]))
+	if C.chromaprint_feed(ctx, samples, C.int(len(pcm)/bytesPerSample)) == 0 {
+		return "", 0, fmt.Errorf("chromaprint_feed failed")
+	}
+	if C.chromaprint_finish(ctx) == 0 {
+		return "", 0, fmt.Errorf("chromaprint_finish failed")
+	}
+
+	var cFingerprint *C.char
+	if C.chromaprint_get_fingerprint(ctx, &cFingerprint) == 0 {
+		return "", 0, fmt.Errorf("chromaprint_get_fingerprint failed")
+	}
+	defer C.chromaprint_dealloc(unsafe.Pointer(cFingerprint))
+
+	numSamples := len(pcm) / bytesPerSample / Channels
+	durationSeconds = numSamples / SampleRate
+	return C.GoString(cFingerprint), durationSeconds, nil
+}
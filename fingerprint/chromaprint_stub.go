@@ -0,0 +1,20 @@
+//go:build !chromaprint
+
+package fingerprint
+
+import "fmt"
+
+// Supported reports whether this binary can actually fingerprint audio.
+// It's false here since chromaprint_stub.go is only built without the
+// chromaprint tag.
+func Supported() bool {
+	return false
+}
+
+// Compute is a stub used when the binary is built without the chromaprint
+// build tag (i.e. without libchromaprint installed). It always fails, so
+// callers fall back to their normal behaviour of giving up on metadata-less
+// audio rather than failing to build at all.
+func Compute(pcmPath string) (fingerprint string, durationSeconds int, err error) {
+	return "", 0, fmt.Errorf("fingerprint: built without chromaprint support (rebuild with -tags chromaprint)")
+}
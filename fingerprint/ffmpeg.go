@@ -0,0 +1,49 @@
+// Package fingerprint decodes audio files to raw PCM via ffmpeg and computes
+// Chromaprint-compatible fingerprints from the result, for looking up
+// untitled audio against AcoustID.
+package fingerprint
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// SampleRate and channel count ffmpeg is asked to decode to; this matches
+// what AcoustID's reference fingerprinter (fpcalc) uses.
+const (
+	SampleRate = 16000
+	Channels   = 1
+)
+
+// DecodeToPCM shells out to ffmpeg to decode the audio file at inputPath
+// into a temporary raw signed 16-bit little-endian PCM file at SampleRate/
+// Channels. The caller is responsible for calling the returned cleanup func
+// to remove the temporary file.
+func DecodeToPCM(ffmpegPath, inputPath string) (pcmPath string, cleanup func(), err error) {
+	out, err := os.CreateTemp("", "fingerprint-*.pcm")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating temp PCM file: %w", err)
+	}
+	pcmPath = out.Name()
+	cleanup = func() { _ = os.Remove(pcmPath) }
+	if err = out.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("closing temp PCM file: %w", err)
+	}
+
+	cmd := exec.Command(ffmpegPath,
+		"-y",
+		"-i", inputPath,
+		"-ar", fmt.Sprintf("%d", SampleRate),
+		"-ac", fmt.Sprintf("%d", Channels),
+		"-f", "s16le",
+		pcmPath,
+	)
+	if output, errRun := cmd.CombinedOutput(); errRun != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("ffmpeg decode failed: %w: %s", errRun, output)
+	}
+
+	return pcmPath, cleanup, nil
+}
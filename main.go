@@ -7,10 +7,15 @@ import (
 	"flag"
 	"fmt"
 	"html/template"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -18,12 +23,24 @@ import (
 	"github.com/PaulSonOfLars/gotgbot/v2"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/callbackquery"
+	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/inlinequery"
 	"github.com/PaulSonOfLars/gotgbot/v2/ext/handlers/filters/message"
 	"github.com/joho/godotenv"
 	log "github.com/sirupsen/logrus"
 	"github.com/zmb3/spotify/v2"
 	auth "github.com/zmb3/spotify/v2/auth"
 	"golang.org/x/oauth2/clientcredentials"
+
+	"telegram-bot-audio-find-spotify/acoustid"
+	botauth "telegram-bot-audio-find-spotify/auth"
+	"telegram-bot-audio-find-spotify/chatsettings"
+	"telegram-bot-audio-find-spotify/fingerprint"
+	"telegram-bot-audio-find-spotify/providers"
+	"telegram-bot-audio-find-spotify/providers/applemusic"
+	"telegram-bot-audio-find-spotify/providers/deezer"
+	spotifyprovider "telegram-bot-audio-find-spotify/providers/spotify"
+	"telegram-bot-audio-find-spotify/ratelimit"
 )
 
 const (
@@ -33,6 +50,112 @@ const (
 
 var spotifyClient *spotify.Client
 
+// authManager drives the Spotify account-linking flow. It stays nil when
+// SPOTIFY_REDIRECT_URL is unset, in which case /login and /logout report
+// that linking is unavailable.
+var authManager *botauth.Manager
+
+// acoustIDClient resolves untitled audio via fingerprinting. It stays nil
+// when ACOUSTID_API_KEY is unset, in which case handleAudioMessage falls
+// back to its old behaviour of giving up on metadata-less audio.
+var acoustIDClient *acoustid.AcoustIDClient
+
+// rateLimiter throttles how often handleAudioMessage reacts to a given user
+// within a given group chat.
+var rateLimiter *ratelimit.Limiter
+
+// chatSettingsStore tracks per-chat opt-in for group-chat audio matching.
+var chatSettingsStore chatsettings.Store
+
+// enabledProviders are the MusicProvider backends enabled via PROVIDERS, in
+// configured order. When spotifyProviderName is among them, handleAudioMessage
+// runs Spotify's richer, account-linked result flow for the primary track;
+// otherwise it falls back to a provider-agnostic flow driven entirely off
+// this list.
+var enabledProviders []providers.MusicProvider
+
+// extraProviders are the enabledProviders other than Spotify.
+// handleAudioMessage queries them alongside the primary result so it can
+// offer one "Open in ..." button per service that found the track.
+var extraProviders []providers.MusicProvider
+
+// spotifyEnabled reports whether "spotify" is among PROVIDERS.
+var spotifyEnabled bool
+
+// spotifyProviderName is providerRegistry's key for the Spotify backend.
+const spotifyProviderName = "spotify"
+
+// maxCandidates caps how many Spotify matches handleAudioMessage offers as
+// disambiguation buttons: the top match plus up to maxCandidates-1 alternates.
+const maxCandidates = 5
+
+// candidateTTL is how long a disambiguation reply's alternate tracks stay
+// selectable before trackCandidates forgets them.
+const candidateTTL = 15 * time.Minute
+
+// trackCandidates holds the alternate-match lists behind disambiguation
+// buttons, keyed by the bot's reply message ID.
+var trackCandidates = newCandidateCache(candidateTTL)
+
+type candidateCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int64]candidateEntry
+}
+
+type candidateEntry struct {
+	tracks    []spotify.FullTrack
+	expiresAt time.Time
+}
+
+func newCandidateCache(ttl time.Duration) *candidateCache {
+	c := &candidateCache{
+		ttl:     ttl,
+		entries: make(map[int64]candidateEntry),
+	}
+	go c.sweepLoop()
+	return c
+}
+
+// sweepLoop periodically evicts expired entries so that disambiguation
+// replies nobody taps don't sit in entries forever; Get alone only expires
+// an entry once something asks for it by ID.
+func (c *candidateCache) sweepLoop() {
+	ticker := time.NewTicker(c.ttl)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.sweep()
+	}
+}
+
+func (c *candidateCache) sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for messageID, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, messageID)
+		}
+	}
+}
+
+func (c *candidateCache) Set(messageID int64, tracks []spotify.FullTrack) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[messageID] = candidateEntry{tracks: tracks, expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (c *candidateCache) Get(messageID int64) ([]spotify.FullTrack, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[messageID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(c.entries, messageID)
+		return nil, false
+	}
+	return entry.tracks, true
+}
+
 func getEnvOrFatal(key string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -41,6 +164,37 @@ func getEnvOrFatal(key string) string {
 	return value
 }
 
+func getEnvOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvIntOrDefault(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		exit(fmt.Sprintf("Invalid integer for environment variable %s: %s", key, value))
+	}
+	return parsed
+}
+
+func getEnvFloatOrDefault(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		exit(fmt.Sprintf("Invalid number for environment variable %s: %s", key, value))
+	}
+	return parsed
+}
+
 func exit(msg string) {
 	_, _ = fmt.Fprintln(os.Stderr, msg)
 	os.Exit(1)
@@ -82,28 +236,110 @@ func main() {
 	log.Debug("Environment: ", environment)
 
 	telegramToken := getEnvOrFatal("TELEGRAM_TOKEN")
-	spotifyClientID := getEnvOrFatal("SPOTIFY_CLIENT_ID")
-	spotifyClientSecret := getEnvOrFatal("SPOTIFY_CLIENT_SECRET")
 
 	bot, err := gotgbot.NewBot(telegramToken, nil)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to create new bot")
 	}
 
-	// Set up a Spotify API client
-	config := &clientcredentials.Config{
-		ClientID:     spotifyClientID,
-		ClientSecret: spotifyClientSecret,
-		TokenURL:     auth.TokenURL,
+	// Parse which music-provider backends PROVIDERS requests before setting
+	// up any of them, so a Deezer/Apple-only deployment never needs Spotify
+	// API credentials.
+	providerNames := strings.Split(getEnvOrDefault("PROVIDERS", spotifyProviderName), ",")
+	for i, name := range providerNames {
+		providerNames[i] = strings.TrimSpace(name)
+	}
+	spotifyRequested := false
+	for _, name := range providerNames {
+		if name == spotifyProviderName {
+			spotifyRequested = true
+			break
+		}
 	}
 
-	token, err := config.Token(context.Background())
-	if err != nil {
-		log.WithError(err).Fatal("Error during Spotify token creation")
+	providerRegistry := map[string]providers.MusicProvider{
+		"deezer": deezer.NewProvider(),
+		"apple":  applemusic.NewProvider(),
 	}
 
-	httpClient := auth.New().Client(context.Background(), token)
-	spotifyClient = spotify.New(httpClient)
+	if spotifyRequested {
+		spotifyClientID := getEnvOrFatal("SPOTIFY_CLIENT_ID")
+		spotifyClientSecret := getEnvOrFatal("SPOTIFY_CLIENT_SECRET")
+
+		// Set up a Spotify API client
+		config := &clientcredentials.Config{
+			ClientID:     spotifyClientID,
+			ClientSecret: spotifyClientSecret,
+			TokenURL:     auth.TokenURL,
+		}
+
+		token, err := config.Token(context.Background())
+		if err != nil {
+			log.WithError(err).Fatal("Error during Spotify token creation")
+		}
+
+		httpClient := auth.New().Client(context.Background(), token)
+		spotifyClient = spotify.New(httpClient)
+		providerRegistry[spotifyProviderName] = spotifyprovider.NewProvider(spotifyClient)
+
+		// Set up the optional Spotify account-linking subsystem.
+		if redirectURL := os.Getenv("SPOTIFY_REDIRECT_URL"); redirectURL != "" {
+			tokenStorePath := getEnvOrDefault("SPOTIFY_TOKEN_STORE_PATH", "spotify_tokens.json")
+			callbackAddr := getEnvOrDefault("SPOTIFY_CALLBACK_ADDR", ":8080")
+			authManager = botauth.NewManager(spotifyClientID, spotifyClientSecret, redirectURL, callbackAddr,
+				botauth.NewJSONFileStore(tokenStorePath))
+			go func() {
+				if errServe := authManager.ListenAndServe(); errServe != nil {
+					log.WithError(errServe).Error("Spotify OAuth callback server stopped")
+				}
+			}()
+			log.Debug("Spotify account linking enabled, callback server listening on ", callbackAddr)
+		} else {
+			log.Debug("SPOTIFY_REDIRECT_URL not set, Spotify account linking disabled")
+		}
+	} else {
+		log.Debug("\"spotify\" not in PROVIDERS, skipping Spotify API setup")
+	}
+
+	// Set up the optional AcoustID fingerprinting fallback.
+	if acoustIDAPIKey := os.Getenv("ACOUSTID_API_KEY"); acoustIDAPIKey != "" {
+		if !fingerprint.Supported() {
+			log.Warn("ACOUSTID_API_KEY is set but this binary was built without chromaprint support (rebuild with -tags chromaprint); fingerprinting will fail")
+		}
+		acoustIDClient = acoustid.NewAcoustIDClient(acoustIDAPIKey)
+		log.Debug("AcoustID fingerprinting fallback enabled")
+	} else {
+		log.Debug("ACOUSTID_API_KEY not set, AcoustID fingerprinting fallback disabled")
+	}
+
+	// Set up group-chat rate limiting and per-chat opt-in.
+	rateLimiter = ratelimit.New(
+		getEnvIntOrDefault("RATE_BURST", 5),
+		getEnvFloatOrDefault("RATE_REFILL_PER_MIN", 5),
+	)
+	chatSettingsStore = chatsettings.NewJSONFileStore(getEnvOrDefault("CHAT_SETTINGS_STORE_PATH", "chat_settings.json"))
+
+	// Set up the pluggable music-provider backends. PROVIDERS controls which
+	// ones are active, Spotify included; if "spotify" is among them,
+	// handleAudioMessage uses Spotify's richer account-linked flow for the
+	// primary result and the rest as extra "Open in ..." buttons, otherwise
+	// it picks a primary from whichever providers are enabled.
+	for _, name := range providerNames {
+		if name == "" {
+			continue
+		}
+		provider, ok := providerRegistry[name]
+		if !ok {
+			log.Warn("Unknown music provider in PROVIDERS, skipping: ", name)
+			continue
+		}
+		enabledProviders = append(enabledProviders, provider)
+		if name == spotifyProviderName {
+			spotifyEnabled = true
+			continue
+		}
+		extraProviders = append(extraProviders, provider)
+	}
 
 	dispatcher := ext.NewDispatcher(&ext.DispatcherOpts{
 		// If a handler returns an error, log it and continue going.
@@ -115,6 +351,12 @@ func main() {
 	dispatcher.AddHandlerToGroup(&HandleAnything{}, -1)
 
 	dispatcher.AddHandler(handlers.NewMessage(message.Audio, handleAudioMessage))
+	dispatcher.AddHandler(handlers.NewInlineQuery(inlinequery.All, handleInlineQuery))
+	dispatcher.AddHandler(handlers.NewCommand("login", handleLoginCommand))
+	dispatcher.AddHandler(handlers.NewCommand("logout", handleLogoutCommand))
+	dispatcher.AddHandler(handlers.NewCommand("enable", handleEnableCommand))
+	dispatcher.AddHandler(handlers.NewCommand("disable", handleDisableCommand))
+	dispatcher.AddHandler(handlers.NewCallback(callbackquery.All, handleLibraryCallback))
 	dispatcher.AddHandler(handlers.NewMessage(message.All, handleUnknownMessage))
 
 	updater := ext.NewUpdater(
@@ -195,6 +437,24 @@ func (h *HandleAnything) Name() string {
 
 func handleAudioMessage(bot *gotgbot.Bot, ctx *ext.Context) (err error) {
 	msg := ctx.EffectiveMessage
+	chat := ctx.EffectiveChat
+
+	if chat.Type == "group" || chat.Type == "supergroup" {
+		enabled, errEnabled := chatSettingsStore.IsEnabled(chat.Id)
+		if errEnabled != nil {
+			log.WithError(errEnabled).Error("Failed to read chat settings")
+		}
+		if !enabled {
+			return nil
+		}
+		if strings.Contains(msg.Caption, "open.spotify.com") {
+			return nil
+		}
+		if !rateLimiter.Allow(chat.Id, ctx.EffectiveUser.Id) {
+			return nil
+		}
+	}
+
 	title := strings.TrimSpace(msg.Audio.Title)
 	author := strings.TrimSpace(msg.Audio.Performer)
 	query := strings.TrimSpace(fmt.Sprintf("%s %s", title, author))
@@ -204,12 +464,25 @@ func handleAudioMessage(bot *gotgbot.Bot, ctx *ext.Context) (err error) {
 		query = strings.TrimSuffix(query, ".mp3")
 		query = strings.TrimSpace(query)
 	}
+	if query == "" {
+		query = resolveQueryByFingerprint(bot, msg)
+	}
 	if query == "" {
 		_, errSendMsg := msg.Reply(bot, "Audio metadata or filename is missing.", nil)
 		checkSendMsgErr(errSendMsg)
 		return fmt.Errorf("audio metadata or filename is missing")
 	}
 
+	if !spotifyEnabled {
+		return handleGenericAudioResult(bot, msg, query)
+	}
+	return handleSpotifyAudioResult(bot, ctx, msg, query)
+}
+
+// handleSpotifyAudioResult renders the Spotify-linked-account result flow:
+// a top match plus disambiguation buttons for the rest, and Save/Add-to-
+// Playlist buttons when the caller has linked their Spotify account.
+func handleSpotifyAudioResult(bot *gotgbot.Bot, ctx *ext.Context, msg *gotgbot.Message, query string) (err error) {
 	results, err := searchSpotify(query)
 	if err != nil {
 		_, errSendMsg := msg.Reply(bot, "Failed to search Spotify.", nil)
@@ -221,35 +494,238 @@ func handleAudioMessage(bot *gotgbot.Bot, ctx *ext.Context) (err error) {
 		Text: "Search",
 		Url:  buildSpotifyUserSearchURL(query),
 	}
+	providerButtons := buildProviderButtons(searchExtraProviders(context.Background(), query))
 	total := results.Tracks.Total
 	if total == 0 {
 		text := fmt.Sprintf("No results found on Spotify by query `%s`", query)
+		keyboard := append([][]gotgbot.InlineKeyboardButton{{searchBtn}}, providerButtons...)
 		opts := &gotgbot.SendMessageOpts{
 			ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
-				InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{searchBtn}},
+				InlineKeyboard: keyboard,
 			},
 		}
 		_, err = msg.Reply(bot, text, opts)
 		return
 	}
-	track := results.Tracks.Tracks[0]
+	candidateCount := len(results.Tracks.Tracks)
+	if candidateCount > maxCandidates {
+		candidateCount = maxCandidates
+	}
+	candidates := results.Tracks.Tracks[:candidateCount]
+	track := candidates[0]
+
 	text, err := buildResultText(&track)
 	if err != nil {
 		return err
 	}
-	_, errSendMsg := msg.Reply(bot, text,
+	keyboard := [][]gotgbot.InlineKeyboardButton{{searchBtn}}
+	if authManager != nil {
+		if authorized, errAuth := authManager.IsAuthorized(ctx.EffectiveUser.Id); errAuth == nil && authorized {
+			keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+				{Text: "❤ Save", CallbackData: callbackSavePrefix + string(track.ID)},
+				{Text: "➕ Add to Playlist…", CallbackData: callbackPlaylistMenuPrefix + string(track.ID)},
+			})
+		}
+	}
+	keyboard = append(keyboard, providerButtons...)
+	sentMsg, errSendMsg := msg.Reply(bot, text,
 		&gotgbot.SendMessageOpts{
 			ParseMode: parsemode.Html,
 			LinkPreviewOptions: &gotgbot.LinkPreviewOptions{
+				Url:              trackCoverImageURL(&track),
 				PreferSmallMedia: true,
 				ShowAboveText:    true,
 			},
 			ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
-				InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{searchBtn}},
+				InlineKeyboard: keyboard,
 			},
 		},
 	)
-	return errSendMsg
+	if errSendMsg != nil {
+		return errSendMsg
+	}
+
+	if len(candidates) > 1 {
+		trackCandidates.Set(sentMsg.MessageId, candidates)
+		keyboard = append(keyboard, buildDisambiguationKeyboard(sentMsg.MessageId, candidates)...)
+		if _, _, errEdit := sentMsg.EditReplyMarkup(bot, &gotgbot.EditMessageReplyMarkupOpts{
+			ReplyMarkup: gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+		}); errEdit != nil {
+			log.WithError(errEdit).Error("Failed to attach disambiguation buttons")
+		}
+	}
+	return nil
+}
+
+// handleGenericAudioResult renders the provider-agnostic result flow used
+// when "spotify" isn't in PROVIDERS: it queries every enabledProviders
+// backend, shows the first match as the primary result with a "Search"
+// button for its own service, and the rest as "Open in ..." buttons. It
+// has no disambiguation or Save/Add-to-Playlist buttons, since those are
+// tied to a linked Spotify account.
+func handleGenericAudioResult(bot *gotgbot.Bot, msg *gotgbot.Message, query string) (err error) {
+	matches := searchProviders(context.Background(), enabledProviders, query)
+	if len(matches) == 0 {
+		_, err = msg.Reply(bot, fmt.Sprintf("No results found by query `%s`", query), nil)
+		return
+	}
+
+	primary := matches[0]
+	text, err := buildGenericResultText(primary.track)
+	if err != nil {
+		return err
+	}
+	keyboard := [][]gotgbot.InlineKeyboardButton{{
+		{Text: "Search", Url: primary.provider.UserSearchURL(query)},
+	}}
+	keyboard = append(keyboard, buildProviderButtons(matches[1:])...)
+
+	_, err = msg.Reply(bot, text,
+		&gotgbot.SendMessageOpts{
+			ParseMode: parsemode.Html,
+			LinkPreviewOptions: &gotgbot.LinkPreviewOptions{
+				Url:              primary.track.ImageURL,
+				PreferSmallMedia: true,
+				ShowAboveText:    true,
+			},
+			ReplyMarkup: &gotgbot.InlineKeyboardMarkup{
+				InlineKeyboard: keyboard,
+			},
+		},
+	)
+	return err
+}
+
+// buildDisambiguationKeyboard renders one button per alternate candidate
+// (i.e. every track but the first), encoding the selection as
+// "v1:<msgID>:<idx>" so handleCandidateSelectCallback can look the full
+// track back up in trackCandidates.
+func buildDisambiguationKeyboard(msgID int64, candidates []spotify.FullTrack) [][]gotgbot.InlineKeyboardButton {
+	rows := make([][]gotgbot.InlineKeyboardButton, 0, len(candidates)-1)
+	for i := 1; i < len(candidates); i++ {
+		track := candidates[i]
+		artists := make([]string, len(track.Artists))
+		for j, artist := range track.Artists {
+			artists[j] = artist.Name
+		}
+		label := fmt.Sprintf("%d. %s — %s", i+1, strings.Join(artists, ", "), track.Name)
+		rows = append(rows, []gotgbot.InlineKeyboardButton{{
+			Text:         label,
+			CallbackData: fmt.Sprintf("%s%d:%d", callbackCandidatePrefix, msgID, i),
+		}})
+	}
+	return rows
+}
+
+// inlineResultsLimit is the page size used for inline query pagination; it is
+// also the step added to the offset when requesting the next page.
+const inlineResultsLimit = 10
+
+// handleInlineQuery remains Spotify-only: its rich per-track rendering
+// (duration, explicit flag, release year) and its offset-based pagination
+// both depend on spotify.FullTrack and spotify.SearchResult.Tracks.Total
+// directly, neither of which the generic providers.MusicProvider interface
+// exposes. It returns no results when "spotify" isn't in PROVIDERS rather
+// than silently querying a Spotify client the operator may not want
+// configured.
+func handleInlineQuery(bot *gotgbot.Bot, ctx *ext.Context) error {
+	iq := ctx.InlineQuery
+	query := strings.TrimSpace(iq.Query)
+	if query == "" || !spotifyEnabled {
+		_, err := iq.Answer(bot, []gotgbot.InlineQueryResult{}, nil)
+		return err
+	}
+
+	offset := 0
+	if parsed, errAtoi := strconv.Atoi(iq.Offset); errAtoi == nil {
+		offset = parsed
+	}
+
+	results, err := spotifyClient.Search(context.Background(), query, spotify.SearchTypeTrack,
+		spotify.Limit(inlineResultsLimit), spotify.Offset(offset))
+	if err != nil {
+		log.WithError(err).Error("Failed to search Spotify for inline query")
+		return err
+	}
+
+	articles := make([]gotgbot.InlineQueryResult, 0, len(results.Tracks.Tracks))
+	for i, track := range results.Tracks.Tracks {
+		text, errText := buildResultText(&track)
+		if errText != nil {
+			log.WithError(errText).Error("Failed to build inline result text")
+			continue
+		}
+		articles = append(articles, gotgbot.InlineQueryResultArticle{
+			Id:           fmt.Sprintf("%s:%d", track.ID, offset+i),
+			Title:        track.Name,
+			Description:  buildInlineResultDescription(&track),
+			ThumbnailUrl: trackThumbnailURL(&track),
+			InputMessageContent: gotgbot.InputTextMessageContent{
+				MessageText: text,
+				ParseMode:   parsemode.Html,
+				LinkPreviewOptions: &gotgbot.LinkPreviewOptions{
+					PreferSmallMedia: true,
+					ShowAboveText:    true,
+				},
+			},
+		})
+	}
+
+	nextOffset := ""
+	if offset+len(results.Tracks.Tracks) < int(results.Tracks.Total) {
+		nextOffset = strconv.Itoa(offset + inlineResultsLimit)
+	}
+
+	_, err = iq.Answer(bot, articles, &gotgbot.AnswerInlineQueryOpts{
+		CacheTime:  10,
+		NextOffset: nextOffset,
+	})
+	return err
+}
+
+// buildInlineResultDescription renders the "artist — album" subtitle shown
+// under each inline query result.
+func buildInlineResultDescription(track *spotify.FullTrack) string {
+	artists := make([]string, len(track.Artists))
+	for i, artist := range track.Artists {
+		artists[i] = artist.Name
+	}
+	return fmt.Sprintf("%s — %s", strings.Join(artists, ", "), track.Album.Name)
+}
+
+// trackThumbnailURL returns the smallest cover art image for the track, or
+// an empty string if the album has no images.
+func trackThumbnailURL(track *spotify.FullTrack) string {
+	images := track.Album.Images
+	if len(images) == 0 {
+		return ""
+	}
+	return images[len(images)-1].URL
+}
+
+// trackCoverImageURL returns the largest cover art image for the track, or
+// an empty string if the album has no images.
+func trackCoverImageURL(track *spotify.FullTrack) string {
+	images := track.Album.Images
+	if len(images) == 0 {
+		return ""
+	}
+	return images[0].URL
+}
+
+// releaseYear extracts the year from a Spotify release_date, which may be
+// precise to the day, month, or year alone.
+func releaseYear(releaseDate string) string {
+	if len(releaseDate) < 4 {
+		return ""
+	}
+	return releaseDate[:4]
+}
+
+// formatTrackDuration renders a track length in milliseconds as "m:ss".
+func formatTrackDuration(durationMs int) string {
+	totalSeconds := durationMs / 1000
+	return fmt.Sprintf("%d:%02d", totalSeconds/60, totalSeconds%60)
 }
 
 func checkSendMsgErr(err error) {
@@ -264,14 +740,443 @@ func handleUnknownMessage(bot *gotgbot.Bot, ctx *ext.Context) error {
 	return errSendMsg
 }
 
+func handleLoginCommand(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if authManager == nil {
+		_, errSendMsg := ctx.EffectiveMessage.Reply(bot, "Spotify account linking is not configured.", nil)
+		return errSendMsg
+	}
+	authURL, err := authManager.AuthURL(ctx.EffectiveUser.Id)
+	if err != nil {
+		log.WithError(err).Error("Failed to build Spotify authorization URL")
+		_, errSendMsg := ctx.EffectiveMessage.Reply(bot, "Failed to start Spotify authorization.", nil)
+		checkSendMsgErr(errSendMsg)
+		return err
+	}
+	return sendDMOrPrompt(bot, ctx, "Tap below to connect your Spotify account:",
+		&gotgbot.InlineKeyboardMarkup{
+			InlineKeyboard: [][]gotgbot.InlineKeyboardButton{{
+				{Text: "Connect Spotify", Url: authURL},
+			}},
+		},
+	)
+}
+
+// sendDMOrPrompt DMs text to the command's caller, since it may contain an
+// authorization link or otherwise reveal that this user is linking an
+// account — details that shouldn't be posted into a group chat. If the bot
+// can't message the user directly (they've never started a private chat
+// with it), it falls back to telling them so in the chat the command was
+// typed in, without leaking text or markup.
+func sendDMOrPrompt(bot *gotgbot.Bot, ctx *ext.Context, text string, markup *gotgbot.InlineKeyboardMarkup) error {
+	_, err := bot.SendMessage(ctx.EffectiveUser.Id, text, &gotgbot.SendMessageOpts{ReplyMarkup: markup})
+	if err == nil {
+		return nil
+	}
+	log.WithError(err).Warn("Failed to DM user, prompting them to start a private chat instead")
+	if ctx.EffectiveChat.Id == ctx.EffectiveUser.Id {
+		return err
+	}
+	_, errSendMsg := ctx.EffectiveMessage.Reply(bot, "Message me privately first, then run this command again.", nil)
+	checkSendMsgErr(errSendMsg)
+	return err
+}
+
+func handleLogoutCommand(bot *gotgbot.Bot, ctx *ext.Context) error {
+	if authManager == nil {
+		_, errSendMsg := ctx.EffectiveMessage.Reply(bot, "Spotify account linking is not configured.", nil)
+		return errSendMsg
+	}
+	if err := authManager.Logout(ctx.EffectiveUser.Id); err != nil {
+		log.WithError(err).Error("Failed to remove stored Spotify authorization")
+		_, errSendMsg := ctx.EffectiveMessage.Reply(bot, "Failed to disconnect Spotify account.", nil)
+		checkSendMsgErr(errSendMsg)
+		return err
+	}
+	return sendDMOrPrompt(bot, ctx, "Spotify account disconnected.", nil)
+}
+
+func handleEnableCommand(bot *gotgbot.Bot, ctx *ext.Context) error {
+	return handleChatToggleCommand(bot, ctx, true)
+}
+
+func handleDisableCommand(bot *gotgbot.Bot, ctx *ext.Context) error {
+	return handleChatToggleCommand(bot, ctx, false)
+}
+
+// handleChatToggleCommand implements /enable and /disable: group-admin-only
+// commands that flip whether handleAudioMessage reacts in this group at all.
+func handleChatToggleCommand(bot *gotgbot.Bot, ctx *ext.Context, enabled bool) error {
+	chat := ctx.EffectiveChat
+	if chat.Type != "group" && chat.Type != "supergroup" {
+		_, err := ctx.EffectiveMessage.Reply(bot, "This command only applies to group chats.", nil)
+		return err
+	}
+
+	isAdmin, err := isChatAdmin(bot, chat.Id, ctx.EffectiveUser.Id)
+	if err != nil {
+		log.WithError(err).Error("Failed to check chat admin status")
+		_, errSendMsg := ctx.EffectiveMessage.Reply(bot, "Failed to verify admin status.", nil)
+		checkSendMsgErr(errSendMsg)
+		return err
+	}
+	if !isAdmin {
+		_, errSendMsg := ctx.EffectiveMessage.Reply(bot, "Only chat admins can change this setting.", nil)
+		return errSendMsg
+	}
+
+	if err = chatSettingsStore.SetEnabled(chat.Id, enabled); err != nil {
+		log.WithError(err).Error("Failed to update chat settings")
+		_, errSendMsg := ctx.EffectiveMessage.Reply(bot, "Failed to update chat settings.", nil)
+		checkSendMsgErr(errSendMsg)
+		return err
+	}
+
+	text := "Audio matching disabled for this chat."
+	if enabled {
+		text = "Audio matching enabled for this chat."
+	}
+	_, err = ctx.EffectiveMessage.Reply(bot, text, nil)
+	return err
+}
+
+func isChatAdmin(bot *gotgbot.Bot, chatID, userID int64) (bool, error) {
+	member, err := bot.GetChatMember(chatID, userID, nil)
+	if err != nil {
+		return false, fmt.Errorf("getting chat member: %w", err)
+	}
+	status := member.GetStatus()
+	return status == "administrator" || status == "creator", nil
+}
+
+// Callback data prefixes for the buttons attached to a match reply. For the
+// library/playlist buttons, selection state beyond the prefix is just the
+// relevant Spotify IDs; for disambiguation buttons it is "<msgID>:<idx>".
+// Both comfortably fit Telegram's 64-byte callback payload.
+const (
+	callbackSavePrefix         = "save:"
+	callbackPlaylistMenuPrefix = "pls:"
+	callbackPlaylistAddPrefix  = "pladd:"
+	callbackCandidatePrefix    = "v1:"
+)
+
+func handleLibraryCallback(bot *gotgbot.Bot, ctx *ext.Context) error {
+	cb := ctx.CallbackQuery
+	switch {
+	case strings.HasPrefix(cb.Data, callbackSavePrefix):
+		return handleSaveTrackCallback(bot, cb, strings.TrimPrefix(cb.Data, callbackSavePrefix))
+	case strings.HasPrefix(cb.Data, callbackPlaylistMenuPrefix):
+		return handlePlaylistMenuCallback(bot, cb, strings.TrimPrefix(cb.Data, callbackPlaylistMenuPrefix))
+	case strings.HasPrefix(cb.Data, callbackPlaylistAddPrefix):
+		return handlePlaylistAddCallback(bot, cb, strings.TrimPrefix(cb.Data, callbackPlaylistAddPrefix))
+	case strings.HasPrefix(cb.Data, callbackCandidatePrefix):
+		return handleCandidateSelectCallback(bot, cb, strings.TrimPrefix(cb.Data, callbackCandidatePrefix))
+	default:
+		return nil
+	}
+}
+
+// userSpotifyClient builds a Spotify client scoped to the callback's sender,
+// answering the callback with a hint to run /login when that fails.
+func userSpotifyClient(bot *gotgbot.Bot, cb *gotgbot.CallbackQuery) (*spotify.Client, error) {
+	client, err := authManager.Client(context.Background(), cb.From.Id)
+	if err != nil {
+		log.WithError(err).Error("Failed to build user Spotify client")
+		_, errAnswer := cb.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: "Connect your Spotify account with /login first."})
+		checkSendMsgErr(errAnswer)
+	}
+	return client, err
+}
+
+func handleSaveTrackCallback(bot *gotgbot.Bot, cb *gotgbot.CallbackQuery, trackID string) error {
+	client, err := userSpotifyClient(bot, cb)
+	if err != nil {
+		return err
+	}
+	if err = client.AddTracksToLibrary(context.Background(), spotify.ID(trackID)); err != nil {
+		log.WithError(err).Error("Failed to save track to Spotify library")
+		_, errAnswer := cb.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: "Failed to save track."})
+		return errAnswer
+	}
+	_, err = cb.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: "Saved to your Liked Songs."})
+	return err
+}
+
+func handlePlaylistMenuCallback(bot *gotgbot.Bot, cb *gotgbot.CallbackQuery, trackID string) error {
+	client, err := userSpotifyClient(bot, cb)
+	if err != nil {
+		return err
+	}
+	playlists, err := client.CurrentUsersPlaylists(context.Background(), spotify.Limit(5))
+	if err != nil {
+		log.WithError(err).Error("Failed to list Spotify playlists")
+		_, errAnswer := cb.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: "Failed to load your playlists."})
+		return errAnswer
+	}
+	if len(playlists.Playlists) == 0 {
+		_, errAnswer := cb.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: "You have no playlists to add to."})
+		return errAnswer
+	}
+
+	buttons := make([][]gotgbot.InlineKeyboardButton, len(playlists.Playlists))
+	for i, playlist := range playlists.Playlists {
+		buttons[i] = []gotgbot.InlineKeyboardButton{{
+			Text:         playlist.Name,
+			CallbackData: fmt.Sprintf("%s%s:%s", callbackPlaylistAddPrefix, trackID, playlist.ID),
+		}}
+	}
+	if _, _, err = cb.Message.EditText(bot, "Choose a playlist:", &gotgbot.EditMessageTextOpts{
+		ReplyMarkup: gotgbot.InlineKeyboardMarkup{InlineKeyboard: buttons},
+	}); err != nil {
+		return err
+	}
+	_, err = cb.Answer(bot, nil)
+	return err
+}
+
+func handlePlaylistAddCallback(bot *gotgbot.Bot, cb *gotgbot.CallbackQuery, payload string) error {
+	trackID, playlistID, ok := strings.Cut(payload, ":")
+	if !ok {
+		_, err := cb.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: "Invalid selection."})
+		return err
+	}
+
+	client, err := userSpotifyClient(bot, cb)
+	if err != nil {
+		return err
+	}
+	if _, err = client.AddTracksToPlaylist(context.Background(), spotify.ID(playlistID), spotify.ID(trackID)); err != nil {
+		log.WithError(err).Error("Failed to add track to Spotify playlist")
+		_, errAnswer := cb.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: "Failed to add track to playlist."})
+		return errAnswer
+	}
+	_, err = cb.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: "Added to playlist."})
+	return err
+}
+
+// handleCandidateSelectCallback swaps the disambiguation reply to show the
+// chosen alternate track in place of the original top match.
+func handleCandidateSelectCallback(bot *gotgbot.Bot, cb *gotgbot.CallbackQuery, payload string) error {
+	msgIDStr, idxStr, ok := strings.Cut(payload, ":")
+	if !ok {
+		_, err := cb.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: "Invalid selection."})
+		return err
+	}
+	msgID, errMsgID := strconv.ParseInt(msgIDStr, 10, 64)
+	idx, errIdx := strconv.Atoi(idxStr)
+	if errMsgID != nil || errIdx != nil {
+		_, err := cb.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: "Invalid selection."})
+		return err
+	}
+
+	candidates, ok := trackCandidates.Get(msgID)
+	if !ok || idx < 0 || idx >= len(candidates) {
+		_, err := cb.Answer(bot, &gotgbot.AnswerCallbackQueryOpts{Text: "These results have expired, please resend the audio."})
+		return err
+	}
+	track := candidates[idx]
+
+	text, err := buildResultText(&track)
+	if err != nil {
+		return err
+	}
+	artists := make([]string, len(track.Artists))
+	for i, artist := range track.Artists {
+		artists[i] = artist.Name
+	}
+	keyboard := [][]gotgbot.InlineKeyboardButton{{{
+		Text: "Search",
+		Url:  buildSpotifyUserSearchURL(strings.Join(artists, " ") + " " + track.Name),
+	}}}
+	if authManager != nil {
+		if authorized, errAuth := authManager.IsAuthorized(cb.From.Id); errAuth == nil && authorized {
+			keyboard = append(keyboard, []gotgbot.InlineKeyboardButton{
+				{Text: "❤ Save", CallbackData: callbackSavePrefix + string(track.ID)},
+				{Text: "➕ Add to Playlist…", CallbackData: callbackPlaylistMenuPrefix + string(track.ID)},
+			})
+		}
+	}
+	keyboard = append(keyboard, buildDisambiguationKeyboard(msgID, candidates)...)
+
+	if _, _, err = cb.Message.EditText(bot, text, &gotgbot.EditMessageTextOpts{
+		ParseMode: parsemode.Html,
+		LinkPreviewOptions: &gotgbot.LinkPreviewOptions{
+			Url:              trackCoverImageURL(&track),
+			PreferSmallMedia: true,
+			ShowAboveText:    true,
+		},
+		ReplyMarkup: gotgbot.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	}); err != nil {
+		return err
+	}
+	_, err = cb.Answer(bot, nil)
+	return err
+}
+
 type trackData struct {
-	Name, Artists, URL string
+	Name, Artists, Album, Year, Duration, URL string
+	Explicit                                  bool
 }
 
 func searchSpotify(query string) (*spotify.SearchResult, error) {
 	return spotifyClient.Search(context.Background(), query, spotify.SearchTypeTrack)
 }
 
+// providerMatch pairs a non-Spotify MusicProvider with the best track it
+// found for a query.
+type providerMatch struct {
+	provider providers.MusicProvider
+	track    providers.Track
+}
+
+// searchExtraProviders queries every configured extraProviders backend
+// concurrently and returns one match per provider that found a track.
+func searchExtraProviders(ctx context.Context, query string) []providerMatch {
+	return searchProviders(ctx, extraProviders, query)
+}
+
+// searchProviders queries the given MusicProvider backends concurrently and
+// returns one match per provider that found a track, preserving the order
+// providerList was given in.
+func searchProviders(ctx context.Context, providerList []providers.MusicProvider, query string) []providerMatch {
+	if len(providerList) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	matches := make([]*providerMatch, len(providerList))
+	var wg sync.WaitGroup
+	for i, provider := range providerList {
+		wg.Add(1)
+		go func(i int, provider providers.MusicProvider) {
+			defer wg.Done()
+			tracks, err := provider.Search(ctx, query)
+			if err != nil {
+				log.WithError(err).WithField("provider", provider.Name()).Debug("Provider search failed")
+				return
+			}
+			if len(tracks) == 0 {
+				return
+			}
+			matches[i] = &providerMatch{provider: provider, track: tracks[0]}
+		}(i, provider)
+	}
+	wg.Wait()
+
+	results := make([]providerMatch, 0, len(providerList))
+	for _, match := range matches {
+		if match != nil {
+			results = append(results, *match)
+		}
+	}
+	return results
+}
+
+// buildProviderButtons renders one "Open in ..." row per provider match,
+// linking to the matched track when the provider returned one or falling
+// back to a plain search URL otherwise.
+func buildProviderButtons(matches []providerMatch) [][]gotgbot.InlineKeyboardButton {
+	rows := make([][]gotgbot.InlineKeyboardButton, 0, len(matches))
+	for _, match := range matches {
+		trackURL := match.track.URL
+		if trackURL == "" {
+			trackURL = match.provider.UserSearchURL(match.track.Title)
+		}
+		rows = append(rows, []gotgbot.InlineKeyboardButton{{
+			Text: "Open in " + match.provider.Name(),
+			Url:  trackURL,
+		}})
+	}
+	return rows
+}
+
+// resolveQueryByFingerprint identifies audio with no usable title, performer
+// or filename by downloading it, decoding it to PCM via ffmpeg, fingerprinting
+// it, and looking the fingerprint up against AcoustID. It returns an empty
+// string if the fallback is disabled (ACOUSTID_API_KEY or ffmpeg missing) or
+// if any step fails, so callers can fall through to the existing "missing
+// metadata" message.
+func resolveQueryByFingerprint(bot *gotgbot.Bot, msg *gotgbot.Message) string {
+	if acoustIDClient == nil {
+		return ""
+	}
+	ffmpegPath := getEnvOrDefault("FFMPEG_PATH", "ffmpeg")
+	if _, err := exec.LookPath(ffmpegPath); err != nil {
+		log.Debug("ffmpeg binary not found, skipping fingerprint fallback")
+		return ""
+	}
+
+	audioPath, cleanupAudio, err := downloadAudioFile(bot, msg.Audio.FileId)
+	if err != nil {
+		log.WithError(err).Error("Failed to download audio for fingerprinting")
+		return ""
+	}
+	defer cleanupAudio()
+
+	pcmPath, cleanupPCM, err := fingerprint.DecodeToPCM(ffmpegPath, audioPath)
+	if err != nil {
+		log.WithError(err).Error("Failed to decode audio to PCM")
+		return ""
+	}
+	defer cleanupPCM()
+
+	fp, durationSeconds, err := fingerprint.Compute(pcmPath)
+	if err != nil {
+		log.WithError(err).Error("Failed to compute audio fingerprint")
+		return ""
+	}
+
+	title, artist, err := acoustIDClient.Lookup(context.Background(), fp, durationSeconds)
+	if err != nil {
+		log.WithError(err).Debug("AcoustID lookup did not identify the audio")
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s", title, artist))
+}
+
+// downloadAudioFile downloads a Telegram file by ID to a temporary file and
+// returns its path. The caller is responsible for calling the returned
+// cleanup func to remove the temporary file.
+func downloadAudioFile(bot *gotgbot.Bot, fileID string) (path string, cleanup func(), err error) {
+	file, err := bot.GetFile(fileID, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("getting file info: %w", err)
+	}
+	fileURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", bot.Token, file.FilePath)
+
+	resp, err := http.Get(fileURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("downloading file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	out, err := os.CreateTemp("", "audio-*"+fileExtension(file.FilePath))
+	if err != nil {
+		return "", nil, fmt.Errorf("creating temp audio file: %w", err)
+	}
+	path = out.Name()
+	cleanup = func() { _ = os.Remove(path) }
+
+	if _, err = io.Copy(out, resp.Body); err != nil {
+		_ = out.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("writing temp audio file: %w", err)
+	}
+	if err = out.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("closing temp audio file: %w", err)
+	}
+	return path, cleanup, nil
+}
+
+func fileExtension(filePath string) string {
+	if idx := strings.LastIndex(filePath, "."); idx != -1 {
+		return filePath[idx:]
+	}
+	return ""
+}
+
 // buildSpotifyUserSearchURL constructs a Spotify search URL for the user with the given query.
 func buildSpotifyUserSearchURL(query string) string {
 	baseURL := "https://open.spotify.com/search"
@@ -285,15 +1190,49 @@ func buildResultText(track *spotify.FullTrack) (text string, err error) {
 	}
 
 	buf := bytes.Buffer{}
-	tpl, err := template.New("track").Parse(`<a href="{{.URL}}">{{.Name}}</a>
-by <b>{{.Artists}}</b>`)
+	tpl, err := template.New("track").Parse(`<a href="{{.URL}}">{{.Name}}</a>{{if .Explicit}} 🅴{{end}}
+by <b>{{.Artists}}</b>
+<i>{{.Album}}{{if .Year}} ({{.Year}}){{end}} · {{.Duration}}</i>`)
 	if err != nil {
 		return
 	}
 	td := trackData{
-		Name:    track.Name,
-		Artists: strings.Join(artists, ", "),
-		URL:     track.ExternalURLs["spotify"],
+		Name:     track.Name,
+		Artists:  strings.Join(artists, ", "),
+		Album:    track.Album.Name,
+		Year:     releaseYear(track.Album.ReleaseDate),
+		Duration: formatTrackDuration(int(track.Duration)),
+		Explicit: track.Explicit,
+		URL:      track.ExternalURLs["spotify"],
+	}
+	if err = tpl.Execute(&buf, td); err != nil {
+		return
+	}
+	return buf.String(), nil
+}
+
+// genericTrackData is the HTML template data for buildGenericResultText,
+// a stripped-down trackData for providers.Track, which doesn't carry a
+// release year, duration, or explicit flag.
+type genericTrackData struct {
+	Title, Artists, Album, URL string
+}
+
+// buildGenericResultText renders a provider-agnostic track, used by
+// handleGenericAudioResult when no richer Spotify-specific data is available.
+func buildGenericResultText(track providers.Track) (text string, err error) {
+	buf := bytes.Buffer{}
+	tpl, err := template.New("generic-track").Parse(`<a href="{{.URL}}">{{.Title}}</a>
+by <b>{{.Artists}}</b>
+<i>{{.Album}}</i>`)
+	if err != nil {
+		return
+	}
+	td := genericTrackData{
+		Title:   track.Title,
+		Artists: strings.Join(track.Artists, ", "),
+		Album:   track.Album,
+		URL:     track.URL,
 	}
 	if err = tpl.Execute(&buf, td); err != nil {
 		return
@@ -0,0 +1,80 @@
+// Package applemusic adapts Apple's public iTunes Search API to the
+// providers.MusicProvider interface.
+package applemusic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"telegram-bot-audio-find-spotify/providers"
+)
+
+// SearchURL is the iTunes Search API endpoint.
+const SearchURL = "https://itunes.apple.com/search"
+
+// Provider searches Apple Music's catalog via the iTunes Search API. It
+// needs no credentials.
+type Provider struct {
+	httpClient *http.Client
+}
+
+// NewProvider returns an Apple Music MusicProvider.
+func NewProvider() *Provider {
+	return &Provider{httpClient: http.DefaultClient}
+}
+
+func (p *Provider) Name() string {
+	return "Apple Music"
+}
+
+type searchResponse struct {
+	Results []struct {
+		TrackName      string `json:"trackName"`
+		ArtistName     string `json:"artistName"`
+		CollectionName string `json:"collectionName"`
+		TrackViewURL   string `json:"trackViewUrl"`
+		ArtworkURL100  string `json:"artworkUrl100"`
+	} `json:"results"`
+}
+
+func (p *Provider) Search(ctx context.Context, query string) ([]providers.Track, error) {
+	params := url.Values{
+		"media": {"music"},
+		"term":  {query},
+		"limit": {"5"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, SearchURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("building iTunes Search request: %w", err)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling iTunes Search: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var parsed searchResponse
+	if err = json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decoding iTunes Search response: %w", err)
+	}
+
+	tracks := make([]providers.Track, len(parsed.Results))
+	for i, item := range parsed.Results {
+		tracks[i] = providers.Track{
+			Title:    item.TrackName,
+			Artists:  []string{item.ArtistName},
+			Album:    item.CollectionName,
+			URL:      item.TrackViewURL,
+			ImageURL: item.ArtworkURL100,
+		}
+	}
+	return tracks, nil
+}
+
+// UserSearchURL constructs an Apple Music search URL for the user with the given query.
+func (p *Provider) UserSearchURL(query string) string {
+	return "https://music.apple.com/search?term=" + url.QueryEscape(query)
+}
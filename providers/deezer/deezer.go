@@ -0,0 +1,78 @@
+// Package deezer adapts Deezer's public, unauthenticated search API to the
+// providers.MusicProvider interface.
+package deezer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"telegram-bot-audio-find-spotify/providers"
+)
+
+// SearchURL is Deezer's unauthenticated track search endpoint.
+const SearchURL = "https://api.deezer.com/search"
+
+// Provider searches Deezer's public catalog. It needs no credentials.
+type Provider struct {
+	httpClient *http.Client
+}
+
+// NewProvider returns a Deezer MusicProvider.
+func NewProvider() *Provider {
+	return &Provider{httpClient: http.DefaultClient}
+}
+
+func (p *Provider) Name() string {
+	return "Deezer"
+}
+
+type searchResponse struct {
+	Data []struct {
+		Title  string `json:"title"`
+		Link   string `json:"link"`
+		Artist struct {
+			Name string `json:"name"`
+		} `json:"artist"`
+		Album struct {
+			Title       string `json:"title"`
+			CoverMedium string `json:"cover_medium"`
+		} `json:"album"`
+	} `json:"data"`
+}
+
+func (p *Provider) Search(ctx context.Context, query string) ([]providers.Track, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, SearchURL+"?q="+url.QueryEscape(query), nil)
+	if err != nil {
+		return nil, fmt.Errorf("building Deezer request: %w", err)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling Deezer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var parsed searchResponse
+	if err = json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decoding Deezer response: %w", err)
+	}
+
+	tracks := make([]providers.Track, len(parsed.Data))
+	for i, item := range parsed.Data {
+		tracks[i] = providers.Track{
+			Title:    item.Title,
+			Artists:  []string{item.Artist.Name},
+			Album:    item.Album.Title,
+			URL:      item.Link,
+			ImageURL: item.Album.CoverMedium,
+		}
+	}
+	return tracks, nil
+}
+
+// UserSearchURL constructs a Deezer search URL for the user with the given query.
+func (p *Provider) UserSearchURL(query string) string {
+	return "https://www.deezer.com/search/" + url.PathEscape(query)
+}
@@ -0,0 +1,27 @@
+// Package providers generalizes music catalog search behind a common
+// interface so the bot isn't hard-wired to Spotify: each backend (Spotify,
+// Deezer, Apple Music, ...) can be queried the same way and offer users a
+// link to open a match in whichever service they prefer.
+package providers
+
+import "context"
+
+// Track is a provider-agnostic search result.
+type Track struct {
+	Title    string
+	Artists  []string
+	Album    string
+	URL      string
+	ImageURL string
+}
+
+// MusicProvider is a backend that can search a music catalog and build a
+// user-facing search URL for it.
+type MusicProvider interface {
+	// Search returns tracks matching query, best match first.
+	Search(ctx context.Context, query string) ([]Track, error)
+	// Name is the provider's display name, e.g. "Spotify".
+	Name() string
+	// UserSearchURL builds a URL a human can open to search query themselves.
+	UserSearchURL(query string) string
+}
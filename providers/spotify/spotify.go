@@ -0,0 +1,66 @@
+// Package spotify adapts an authenticated zmb3/spotify client to the
+// providers.MusicProvider interface, so Spotify can be enabled, disabled,
+// and listed alongside the other backends via PROVIDERS instead of being
+// wired in as the bot's hardcoded primary source.
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	zspotify "github.com/zmb3/spotify/v2"
+
+	"telegram-bot-audio-find-spotify/providers"
+)
+
+// Provider searches Spotify's catalog via client. client is typically
+// authenticated with the client-credentials flow, which is enough for
+// catalog search without a user login.
+type Provider struct {
+	client *zspotify.Client
+}
+
+// NewProvider returns a Spotify MusicProvider backed by client.
+func NewProvider(client *zspotify.Client) *Provider {
+	return &Provider{client: client}
+}
+
+func (p *Provider) Name() string {
+	return "Spotify"
+}
+
+func (p *Provider) Search(ctx context.Context, query string) ([]providers.Track, error) {
+	results, err := p.client.Search(ctx, query, zspotify.SearchTypeTrack)
+	if err != nil {
+		return nil, fmt.Errorf("searching Spotify: %w", err)
+	}
+	if results.Tracks == nil {
+		return nil, nil
+	}
+
+	tracks := make([]providers.Track, len(results.Tracks.Tracks))
+	for i, track := range results.Tracks.Tracks {
+		artists := make([]string, len(track.Artists))
+		for j, artist := range track.Artists {
+			artists[j] = artist.Name
+		}
+		imageURL := ""
+		if len(track.Album.Images) > 0 {
+			imageURL = track.Album.Images[0].URL
+		}
+		tracks[i] = providers.Track{
+			Title:    track.Name,
+			Artists:  artists,
+			Album:    track.Album.Name,
+			URL:      track.ExternalURLs["spotify"],
+			ImageURL: imageURL,
+		}
+	}
+	return tracks, nil
+}
+
+// UserSearchURL constructs a Spotify search URL for the user with the given query.
+func (p *Provider) UserSearchURL(query string) string {
+	return "https://open.spotify.com/search/" + url.PathEscape(query)
+}
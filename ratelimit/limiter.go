@@ -0,0 +1,95 @@
+// Package ratelimit implements a token-bucket rate limiter for throttling
+// how often the bot reacts to a given user within a given chat, so it stays
+// usable in busy group chats without spamming them.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// key identifies a rate-limit bucket: a (chat, user) pair.
+type key struct {
+	chatID int64
+	userID int64
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// idleTTL is how long a bucket can go untouched before sweep considers it
+// stale and evicts it. Whenever refillPerMinute*60 >= burst, a bucket idle
+// this long has refilled to burst anyway, so dropping it loses no rate-limit
+// state; with a slower refill, an evicted bucket restarts at full burst.
+const idleTTL = time.Hour
+
+// Limiter is a token-bucket rate limiter keyed by (chat_id, user_id).
+type Limiter struct {
+	mu              sync.Mutex
+	burst           int
+	refillPerSecond float64
+	buckets         map[key]*bucket
+}
+
+// New returns a Limiter that allows burst immediate hits per (chat, user)
+// pair, refilled at refillPerMinute tokens per minute.
+func New(burst int, refillPerMinute float64) *Limiter {
+	l := &Limiter{
+		burst:           burst,
+		refillPerSecond: refillPerMinute / 60,
+		buckets:         make(map[key]*bucket),
+	}
+	go l.sweepLoop()
+	return l
+}
+
+// sweepLoop periodically evicts buckets idle longer than idleTTL, so a
+// limiter that has seen many distinct (chat, user) pairs doesn't grow
+// forever.
+func (l *Limiter) sweepLoop() {
+	ticker := time.NewTicker(idleTTL)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.sweep()
+	}
+}
+
+func (l *Limiter) sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cutoff := time.Now().Add(-idleTTL)
+	for k, b := range l.buckets {
+		if b.updatedAt.Before(cutoff) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// Allow reports whether the (chatID, userID) pair currently has a token
+// available, consuming one if so.
+func (l *Limiter) Allow(chatID, userID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	k := key{chatID: chatID, userID: userID}
+	now := time.Now()
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), updatedAt: now}
+		l.buckets[k] = b
+	}
+
+	b.tokens += now.Sub(b.updatedAt).Seconds() * l.refillPerSecond
+	if b.tokens > float64(l.burst) {
+		b.tokens = float64(l.burst)
+	}
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}